@@ -0,0 +1,303 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bucket
+
+import (
+	"encoding/json"
+	"fmt"
+
+	cephrgw "github.com/rook/rook/pkg/ceph/rgw"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reconcileClaim provisions the RGW user and bucket backing claim, if it has
+// not already been provisioned, and projects the resulting credentials and
+// endpoint into a Secret and ConfigMap in the claim's namespace.
+func (c *Controller) reconcileClaim(claim *ObjectBucketClaim) error {
+	if claim.Status.Phase == ClaimStatusBound {
+		return nil
+	}
+
+	if err := c.addFinalizer(claim); err != nil {
+		return fmt.Errorf("failed to add finalizer to claim %s/%s. %+v", claim.Namespace, claim.Name, err)
+	}
+
+	bucketName := claim.Spec.BucketName
+	if bucketName == "" {
+		bucketName = fmt.Sprintf("%s-%s", claim.Namespace, claim.Name)
+	}
+	userID := bucketUserID(claim)
+
+	accessKey, secretKey, err := c.createBucketUser(userID)
+	if err != nil {
+		return c.failClaim(claim, fmt.Errorf("failed to create rgw user %s. %+v", userID, err))
+	}
+
+	endpoint, err := c.rgwEndpoint()
+	if err != nil {
+		return c.failClaim(claim, fmt.Errorf("failed to look up rgw endpoint. %+v", err))
+	}
+
+	s3Client := newS3Client(endpoint, accessKey, secretKey)
+	if err := s3Client.createBucket(bucketName); err != nil {
+		return c.failClaim(claim, fmt.Errorf("failed to create bucket %s. %+v", bucketName, err))
+	}
+
+	if claim.Spec.MaxObjects > 0 || claim.Spec.MaxSize != "" {
+		if err := c.applyQuota(bucketName, claim.Spec.MaxObjects, claim.Spec.MaxSize); err != nil {
+			return c.failClaim(claim, fmt.Errorf("failed to apply quota to bucket %s. %+v", bucketName, err))
+		}
+	}
+
+	if err := c.writeConnectionInfo(claim, bucketName, endpoint, accessKey, secretKey); err != nil {
+		return c.failClaim(claim, fmt.Errorf("failed to store bucket connection info. %+v", err))
+	}
+
+	claim.Status.Phase = ClaimStatusBound
+	claim.Status.Message = ""
+	return c.updateClaimStatus(claim)
+}
+
+// reconcileDelete is invoked when a claim has a deletion timestamp. It honors
+// the claim's retention policy before removing the finalizer so the claim
+// object itself can be deleted.
+func (c *Controller) reconcileDelete(claim *ObjectBucketClaim) error {
+	if !hasFinalizer(claim) {
+		return nil
+	}
+
+	policy := claim.Spec.RetentionPolicy
+	if policy == "" {
+		policy = RetentionPolicyDelete
+	}
+
+	if policy == RetentionPolicyDelete {
+		bucketName := claim.Spec.BucketName
+		if bucketName == "" {
+			bucketName = fmt.Sprintf("%s-%s", claim.Namespace, claim.Name)
+		}
+		userID := bucketUserID(claim)
+
+		if err := c.purgeBucket(bucketName); err != nil {
+			return fmt.Errorf("failed to purge bucket %s. %+v", bucketName, err)
+		}
+		if err := c.deleteBucketUser(userID); err != nil {
+			return fmt.Errorf("failed to delete rgw user %s. %+v", userID, err)
+		}
+	} else {
+		logger.Infof("retention policy Retain set on claim %s/%s, leaving bucket in place", claim.Namespace, claim.Name)
+	}
+
+	return c.removeFinalizer(claim)
+}
+
+func bucketUserID(claim *ObjectBucketClaim) string {
+	return fmt.Sprintf("bucket-%s-%s", claim.Namespace, claim.Name)
+}
+
+type rgwUser struct {
+	Keys []struct {
+		AccessKey string `json:"access_key"`
+		SecretKey string `json:"secret_key"`
+	} `json:"keys"`
+}
+
+// createBucketUser is idempotent: reconcileClaim can re-run it on any
+// requeue (a later step such as bucket creation or quota may have failed
+// after the user was already minted), and "user create" errors if the uid
+// already exists, so an existing user is looked up instead of recreated.
+func (c *Controller) createBucketUser(userID string) (accessKey, secretKey string, err error) {
+	output, err := c.rgwCluster.RunAdminCommand("user", "info", fmt.Sprintf("--uid=%s", userID))
+	if err != nil {
+		output, err = c.rgwCluster.RunAdminCommand("user", "create",
+			fmt.Sprintf("--uid=%s", userID),
+			fmt.Sprintf("--display-name=%s", userID))
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	var user rgwUser
+	if err := json.Unmarshal([]byte(output), &user); err != nil {
+		return "", "", fmt.Errorf("failed to parse rgw user response. %+v", err)
+	}
+	if len(user.Keys) == 0 {
+		return "", "", fmt.Errorf("rgw user %s has no s3 keys", userID)
+	}
+
+	return user.Keys[0].AccessKey, user.Keys[0].SecretKey, nil
+}
+
+func (c *Controller) deleteBucketUser(userID string) error {
+	_, err := c.rgwCluster.RunAdminCommand("user", "rm", fmt.Sprintf("--uid=%s", userID), "--purge-data")
+	return err
+}
+
+// parseMaxSizeBytes converts a Kubernetes binary-suffix quantity (e.g.
+// "10Gi") into the plain byte count radosgw-admin's --max-size expects.
+func parseMaxSizeBytes(maxSize string) (int64, error) {
+	quantity, err := resource.ParseQuantity(maxSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid maxSize %q. %+v", maxSize, err)
+	}
+	return quantity.Value(), nil
+}
+
+func (c *Controller) applyQuota(bucketName string, maxObjects int64, maxSize string) error {
+	args := []string{"quota", "set", "--quota-scope=bucket", fmt.Sprintf("--bucket=%s", bucketName)}
+	if maxObjects > 0 {
+		args = append(args, fmt.Sprintf("--max-objects=%d", maxObjects))
+	}
+	if maxSize != "" {
+		maxSizeBytes, err := parseMaxSizeBytes(maxSize)
+		if err != nil {
+			return err
+		}
+		args = append(args, fmt.Sprintf("--max-size=%d", maxSizeBytes))
+	}
+	if _, err := c.rgwCluster.RunAdminCommand(args...); err != nil {
+		return err
+	}
+
+	_, err := c.rgwCluster.RunAdminCommand("quota", "enable", "--quota-scope=bucket", fmt.Sprintf("--bucket=%s", bucketName))
+	return err
+}
+
+func (c *Controller) purgeBucket(bucketName string) error {
+	_, err := c.rgwCluster.RunAdminCommand("bucket", "rm", fmt.Sprintf("--bucket=%s", bucketName), "--purge-objects")
+	return err
+}
+
+func (c *Controller) rgwEndpoint() (string, error) {
+	svc, err := c.context.Clientset.CoreV1().Services(c.rgwCluster.Namespace).Get(rgwServiceName(c.rgwCluster.Name), metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d", svc.Spec.ClusterIP, cephrgw.RGWPort), nil
+}
+
+// writeConnectionInfo projects the bucket's access credentials into a Secret
+// and its non-sensitive connection details into a ConfigMap in the claim's
+// namespace, owned by the claim so they are garbage collected along with it.
+func (c *Controller) writeConnectionInfo(claim *ObjectBucketClaim, bucketName, endpoint, accessKey, secretKey string) error {
+	owner := claimOwnerRef(claim)
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            claim.Name,
+			Namespace:       claim.Namespace,
+			OwnerReferences: []metav1.OwnerReference{owner},
+		},
+		StringData: map[string]string{
+			"AWS_ACCESS_KEY_ID":     accessKey,
+			"AWS_SECRET_ACCESS_KEY": secretKey,
+		},
+	}
+	if _, err := c.context.Clientset.CoreV1().Secrets(claim.Namespace).Create(secret); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            claim.Name,
+			Namespace:       claim.Namespace,
+			OwnerReferences: []metav1.OwnerReference{owner},
+		},
+		Data: map[string]string{
+			"BUCKET_NAME": bucketName,
+			"BUCKET_HOST": endpoint,
+		},
+	}
+	if _, err := c.context.Clientset.CoreV1().ConfigMaps(claim.Namespace).Create(configMap); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func claimOwnerRef(claim *ObjectBucketClaim) metav1.OwnerReference {
+	blockOwnerDeletion := true
+	return metav1.OwnerReference{
+		APIVersion:         SchemeGroupVersion.String(),
+		Kind:               "ObjectBucketClaim",
+		Name:               claim.Name,
+		UID:                claim.UID,
+		BlockOwnerDeletion: &blockOwnerDeletion,
+	}
+}
+
+func rgwServiceName(clusterName string) string {
+	return fmt.Sprintf("rook-ceph-rgw-%s", clusterName)
+}
+
+func hasFinalizer(claim *ObjectBucketClaim) bool {
+	for _, f := range claim.Finalizers {
+		if f == Finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Controller) addFinalizer(claim *ObjectBucketClaim) error {
+	if hasFinalizer(claim) {
+		return nil
+	}
+	claim.Finalizers = append(claim.Finalizers, Finalizer)
+	return c.updateClaim(claim)
+}
+
+func (c *Controller) removeFinalizer(claim *ObjectBucketClaim) error {
+	finalizers := claim.Finalizers[:0]
+	for _, f := range claim.Finalizers {
+		if f != Finalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	claim.Finalizers = finalizers
+	return c.updateClaim(claim)
+}
+
+func (c *Controller) failClaim(claim *ObjectBucketClaim, cause error) error {
+	claim.Status.Phase = ClaimStatusFailed
+	claim.Status.Message = cause.Error()
+	if err := c.updateClaimStatus(claim); err != nil {
+		logger.Errorf("failed to record failure status on claim %s/%s. %+v", claim.Namespace, claim.Name, err)
+	}
+	return cause
+}
+
+func (c *Controller) updateClaim(claim *ObjectBucketClaim) error {
+	return c.restClient.Put().
+		Namespace(claim.Namespace).
+		Resource(CustomResourceNamePlural).
+		Name(claim.Name).
+		Body(claim).
+		Do().
+		Into(claim)
+}
+
+func (c *Controller) updateClaimStatus(claim *ObjectBucketClaim) error {
+	return c.updateClaim(claim)
+}
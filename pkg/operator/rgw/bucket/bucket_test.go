@@ -0,0 +1,69 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bucket
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBucketUserID(t *testing.T) {
+	claim := &ObjectBucketClaim{}
+	claim.Namespace = "my-namespace"
+	claim.Name = "my-claim"
+	assert.Equal(t, "bucket-my-namespace-my-claim", bucketUserID(claim))
+}
+
+func TestHasFinalizer(t *testing.T) {
+	claim := &ObjectBucketClaim{}
+	assert.False(t, hasFinalizer(claim))
+
+	claim.Finalizers = []string{"some-other-finalizer"}
+	assert.False(t, hasFinalizer(claim))
+
+	claim.Finalizers = append(claim.Finalizers, Finalizer)
+	assert.True(t, hasFinalizer(claim))
+}
+
+func TestClaimOwnerRef(t *testing.T) {
+	claim := &ObjectBucketClaim{}
+	claim.Name = "my-claim"
+
+	owner := claimOwnerRef(claim)
+	assert.Equal(t, "ObjectBucketClaim", owner.Kind)
+	assert.Equal(t, "my-claim", owner.Name)
+	assert.NotNil(t, owner.BlockOwnerDeletion)
+	assert.True(t, *owner.BlockOwnerDeletion)
+}
+
+func TestParseMaxSizeBytes(t *testing.T) {
+	bytes, err := parseMaxSizeBytes("10Gi")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10*1024*1024*1024), bytes)
+
+	bytes, err = parseMaxSizeBytes("512Mi")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(512*1024*1024), bytes)
+
+	_, err = parseMaxSizeBytes("not-a-quantity")
+	assert.Error(t, err)
+}
+
+func TestRgwServiceName(t *testing.T) {
+	assert.Equal(t, "rook-ceph-rgw-my-cluster", rgwServiceName("my-cluster"))
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bucket
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coreos/pkg/capnslog"
+	"github.com/rook/rook/pkg/clusterd"
+	"github.com/rook/rook/pkg/operator/rgw"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+var logger = capnslog.NewPackageLogger("github.com/rook/rook", "op-rgw-bucket")
+
+// maxRetries is how many times a claim key is requeued after a reconcile
+// failure before it's dropped; the 10-minute informer resync is still the
+// backstop after that.
+const maxRetries = 10
+
+// Controller watches ObjectBucketClaims and provisions/deprovisions RGW
+// buckets, users and quota on their behalf.
+type Controller struct {
+	context    *clusterd.Context
+	rgwCluster *rgw.Cluster
+	restClient rest.Interface
+	store      cache.Store
+	queue      workqueue.RateLimitingInterface
+}
+
+// New creates an ObjectBucketClaim controller that provisions buckets
+// against the RGW started by rgwCluster.Start.
+func New(context *clusterd.Context, rgwCluster *rgw.Cluster) *Controller {
+	return &Controller{
+		context:    context,
+		rgwCluster: rgwCluster,
+		queue:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+}
+
+// StartWatch registers the ObjectBucketClaim CRD if needed and starts
+// watching claims in the given namespace until stopCh is closed.
+func (c *Controller) StartWatch(namespace string, stopCh chan struct{}) error {
+	if err := c.createCustomResource(); err != nil {
+		return fmt.Errorf("failed to create %s CRD. %+v", CustomResourceNamePlural, err)
+	}
+
+	restClient, err := newRESTClient(c.context.KubeConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create object bucket claim client. %+v", err)
+	}
+	c.restClient = restClient
+
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			result := &ObjectBucketClaimList{}
+			err := c.restClient.Get().
+				Namespace(namespace).
+				Resource(CustomResourceNamePlural).
+				VersionedParams(&options, metav1.ParameterCodec).
+				Do().
+				Into(result)
+			return result, err
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.Watch = true
+			return c.restClient.Get().
+				Namespace(namespace).
+				Resource(CustomResourceNamePlural).
+				VersionedParams(&options, metav1.ParameterCodec).
+				Watch()
+		},
+	}
+
+	store, informer := cache.NewInformer(
+		listWatch,
+		&ObjectBucketClaim{},
+		10*time.Minute,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    c.enqueue,
+			UpdateFunc: func(oldObj, newObj interface{}) { c.enqueue(newObj) },
+			DeleteFunc: c.enqueue,
+		},
+	)
+	c.store = store
+
+	logger.Infof("starting object bucket claim watch in namespace %s", namespace)
+	go informer.Run(stopCh)
+	go wait.Until(c.runWorker, time.Second, stopCh)
+
+	go func() {
+		<-stopCh
+		c.queue.ShutDown()
+	}()
+
+	return nil
+}
+
+// enqueue adds the claim's namespace/name key to the workqueue. Handlers
+// only ever enqueue a key; reconcileKey looks the object back up from the
+// informer's store and deep-copies it before mutating anything, so the
+// shared cache is never touched.
+func (c *Controller) enqueue(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		logger.Errorf("failed to compute key for object bucket claim. %+v", err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	err := c.reconcileKey(key.(string))
+	if err == nil {
+		c.queue.Forget(key)
+		return true
+	}
+
+	if c.queue.NumRequeues(key) < maxRetries {
+		logger.Warningf("requeuing object bucket claim %s after error: %+v", key, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	logger.Errorf("giving up reconciling object bucket claim %s after %d retries: %+v", key, maxRetries, err)
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *Controller) reconcileKey(key string) error {
+	obj, exists, err := c.store.GetByKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to look up claim %s. %+v", key, err)
+	}
+	if !exists {
+		// the claim was already fully deleted (its finalizer was already
+		// removed by a prior reconcile), nothing left to do
+		return nil
+	}
+
+	// deep-copy before mutating anything: obj is shared with the informer
+	// cache and every other consumer reading from it
+	claim := obj.(*ObjectBucketClaim).DeepCopy()
+
+	if claim.DeletionTimestamp != nil {
+		return c.reconcileDelete(claim)
+	}
+	return c.reconcileClaim(claim)
+}
+
+func (c *Controller) createCustomResource() error {
+	crd := &apiextensionsv1beta1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s.%s", CustomResourceNamePlural, SchemeGroupVersion.Group)},
+		Spec: apiextensionsv1beta1.CustomResourceDefinitionSpec{
+			Group:   SchemeGroupVersion.Group,
+			Version: SchemeGroupVersion.Version,
+			Scope:   apiextensionsv1beta1.NamespaceScoped,
+			Names: apiextensionsv1beta1.CustomResourceDefinitionNames{
+				Plural:   CustomResourceNamePlural,
+				Singular: CustomResourceName,
+				Kind:     "ObjectBucketClaim",
+				ListKind: "ObjectBucketClaimList",
+			},
+		},
+	}
+
+	_, err := c.context.APIExtensionClientset.ApiextensionsV1beta1().CustomResourceDefinitions().Create(crd)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+func newRESTClient(config *rest.Config) (rest.Interface, error) {
+	scheme := runtime.NewScheme()
+	if err := addKnownTypes(scheme); err != nil {
+		return nil, err
+	}
+
+	configCopy := *config
+	configCopy.GroupVersion = &SchemeGroupVersion
+	configCopy.APIPath = "/apis"
+	configCopy.ContentType = runtime.ContentTypeJSON
+	configCopy.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: serializer.NewCodecFactory(scheme)}
+
+	return rest.RESTClientFor(&configCopy)
+}
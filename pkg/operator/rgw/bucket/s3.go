@@ -0,0 +1,62 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bucket
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// s3Client is a thin wrapper around the AWS S3 SDK pointed at an RGW
+// endpoint, scoped to the credentials of a single bucket's owning user.
+type s3Client struct {
+	svc *s3.S3
+}
+
+func newS3Client(endpoint, accessKey, secretKey string) *s3Client {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Endpoint:         aws.String(fmt.Sprintf("http://%s", endpoint)),
+		Region:           aws.String("us-east-1"),
+		Credentials:      credentials.NewStaticCredentials(accessKey, secretKey, ""),
+		S3ForcePathStyle: aws.Bool(true),
+	}))
+	return &s3Client{svc: s3.New(sess)}
+}
+
+func (s *s3Client) createBucket(name string) error {
+	_, err := s.svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(name)})
+	if err != nil {
+		if awsErr, ok := isBucketAlreadyOwnedByYou(err); ok {
+			logger.Infof("bucket %s already exists: %v", name, awsErr)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func isBucketAlreadyOwnedByYou(err error) (error, bool) {
+	type awsError interface {
+		Code() string
+	}
+	awsErr, ok := err.(awsError)
+	return err, ok && awsErr.Code() == s3.ErrCodeBucketAlreadyOwnedByYou
+}
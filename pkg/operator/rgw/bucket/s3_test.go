@@ -0,0 +1,45 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bucket
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAWSError struct {
+	code string
+}
+
+func (e fakeAWSError) Error() string   { return e.code }
+func (e fakeAWSError) Code() string    { return e.code }
+func (e fakeAWSError) Message() string { return e.code }
+func (e fakeAWSError) OrigErr() error  { return nil }
+
+func TestIsBucketAlreadyOwnedByYou(t *testing.T) {
+	_, ok := isBucketAlreadyOwnedByYou(fakeAWSError{code: s3.ErrCodeBucketAlreadyOwnedByYou})
+	assert.True(t, ok)
+
+	_, ok = isBucketAlreadyOwnedByYou(fakeAWSError{code: s3.ErrCodeBucketAlreadyExists})
+	assert.False(t, ok)
+
+	_, ok = isBucketAlreadyOwnedByYou(errors.New("not an aws error"))
+	assert.False(t, ok)
+}
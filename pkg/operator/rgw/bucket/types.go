@@ -0,0 +1,151 @@
+/*
+Copyright 2016 The Rook Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bucket provides dynamic provisioning of RGW buckets through an
+// ObjectBucketClaim custom resource, analogous to how PersistentVolumeClaims
+// drive dynamic volume provisioning.
+package bucket
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// CustomResourceName is the singular name of the ObjectBucketClaim CRD.
+	CustomResourceName = "objectbucketclaim"
+	// CustomResourceNamePlural is the plural name of the ObjectBucketClaim CRD.
+	CustomResourceNamePlural = "objectbucketclaims"
+
+	// RetentionPolicyDelete purges the bucket and its owning user when the
+	// claim is deleted.
+	RetentionPolicyDelete = "Delete"
+	// RetentionPolicyRetain leaves the bucket and its data in place when the
+	// claim is deleted.
+	RetentionPolicyRetain = "Retain"
+
+	// Finalizer is added to every ObjectBucketClaim so the controller can
+	// run its deletion reconciliation (purge or retain) before the claim is
+	// actually removed from etcd.
+	Finalizer = "rgw.rook.io/bucket"
+)
+
+// ObjectBucketClaimSpec is the desired state of an object bucket. It plays a
+// role similar to a PersistentVolumeClaimSpec, but for an S3 bucket backed by
+// RGW rather than a block device.
+type ObjectBucketClaimSpec struct {
+	// BucketName is the name of the bucket to create. If empty, a name is
+	// generated from the claim's namespace and name.
+	BucketName string `json:"bucketName,omitempty"`
+
+	// MaxObjects limits the number of objects the bucket may hold. Zero
+	// means unlimited.
+	MaxObjects int64 `json:"maxObjects,omitempty"`
+
+	// MaxSize limits the total size of the bucket, e.g. "10Gi". Empty means
+	// unlimited.
+	MaxSize string `json:"maxSize,omitempty"`
+
+	// RetentionPolicy controls what happens to the bucket and its user when
+	// the claim is deleted: Delete (default) or Retain.
+	RetentionPolicy string `json:"retentionPolicy,omitempty"`
+}
+
+// ObjectBucketClaimStatus is the observed state of an ObjectBucketClaim.
+type ObjectBucketClaimStatus struct {
+	Phase   string `json:"phase,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Claim phases.
+const (
+	ClaimStatusPending = "Pending"
+	ClaimStatusBound   = "Bound"
+	ClaimStatusFailed  = "Failed"
+)
+
+// ObjectBucketClaim is a request for a dynamically provisioned RGW bucket.
+type ObjectBucketClaim struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ObjectBucketClaimSpec   `json:"spec"`
+	Status ObjectBucketClaimStatus `json:"status,omitempty"`
+}
+
+// ObjectBucketClaimList is a list of ObjectBucketClaims.
+type ObjectBucketClaimList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []ObjectBucketClaim `json:"items"`
+}
+
+// DeepCopy returns a copy of the claim that is safe to mutate without
+// corrupting the shared informer cache the original came from.
+func (c *ObjectBucketClaim) DeepCopy() *ObjectBucketClaim {
+	if c == nil {
+		return nil
+	}
+	out := &ObjectBucketClaim{}
+	c.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject implements runtime.Object.
+func (c *ObjectBucketClaim) DeepCopyObject() runtime.Object {
+	if c == nil {
+		return nil
+	}
+	return c.DeepCopy()
+}
+
+// DeepCopyObject implements runtime.Object.
+func (c *ObjectBucketClaimList) DeepCopyObject() runtime.Object {
+	if c == nil {
+		return nil
+	}
+	out := *c
+	if c.Items != nil {
+		out.Items = make([]ObjectBucketClaim, len(c.Items))
+		for i := range c.Items {
+			c.Items[i].DeepCopyInto(&out.Items[i])
+		}
+	}
+	return &out
+}
+
+// DeepCopyInto copies all properties into the destination claim.
+func (c *ObjectBucketClaim) DeepCopyInto(out *ObjectBucketClaim) {
+	*out = *c
+	c.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = c.Spec
+	out.Status = c.Status
+}
+
+// SchemeGroupVersion is the group/version used to register the
+// ObjectBucketClaim CRD with the API server.
+var SchemeGroupVersion = schema.GroupVersion{Group: "rgw.rook.io", Version: "v1alpha1"}
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(SchemeGroupVersion,
+		&ObjectBucketClaim{},
+		&ObjectBucketClaimList{},
+	)
+	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
+	return nil
+}
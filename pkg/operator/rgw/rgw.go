@@ -20,6 +20,7 @@ package rgw
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/coreos/pkg/capnslog"
 	"github.com/rook/rook/pkg/ceph/client"
@@ -27,8 +28,10 @@ import (
 	"github.com/rook/rook/pkg/clusterd"
 	"github.com/rook/rook/pkg/operator/k8sutil"
 	opmon "github.com/rook/rook/pkg/operator/mon"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	"k8s.io/api/core/v1"
 	extensions "k8s.io/api/extensions/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -39,6 +42,31 @@ var logger = capnslog.NewPackageLogger("github.com/rook/rook", "op-rgw")
 const (
 	appName     = "rook-ceph-rgw"
 	keyringName = "keyring"
+
+	accessKeyName = "access-key"
+	secretKeyName = "secret-key"
+
+	// periodUpdateInterval is how often a secondary zone re-pulls the realm
+	// configuration and commits a period update so that zone/zonegroup
+	// changes made on the master propagate here.
+	periodUpdateInterval = 2 * time.Minute
+
+	pullRetryInitialBackoff = 5 * time.Second
+	pullRetryMaxBackoff     = 2 * time.Minute
+
+	sslCertVolumeName = "rook-rgw-cert"
+	sslCertMountPath  = "/etc/ceph/private"
+	sslCertFileName   = "rgw-cert.pem"
+
+	defaultSecurePort = 443
+
+	// certWatchInterval is how often the certificate rotation watcher checks
+	// whether the referenced SSL secret has changed.
+	certWatchInterval = time.Minute
+
+	defaultProbeInitialDelaySeconds = 10
+	defaultProbeTimeoutSeconds      = 5
+	defaultProbePeriodSeconds       = 10
 )
 
 // Cluster for rgw management
@@ -49,6 +77,89 @@ type Cluster struct {
 	placement k8sutil.Placement
 	Version   string
 	Replicas  int32
+
+	// Zone is the rgw zone this instance serves. Defaults to Name when empty.
+	Zone string
+
+	// MasterRealmEndpoint is the http(s) endpoint of an existing master zone
+	// in a remote Ceph cluster. When set, Start joins that realm as a
+	// secondary (pull) zone instead of creating a new master realm.
+	MasterRealmEndpoint string
+	AccessKey           string
+	SecretKey           string
+
+	// AllowMasterZoneDowngrade must be set to true to convert an existing
+	// master zone on this cluster into a secondary. Without it, Start
+	// refuses the downgrade to avoid silently demoting a zone that other
+	// clusters may already be pulling from.
+	AllowMasterZoneDowngrade bool
+
+	// Gateway controls how the rgw service is exposed: service type, TLS
+	// termination and optional ingress.
+	Gateway GatewaySpec
+
+	// HealthCheck tunes the readiness/liveness HTTP probes on the rgw
+	// container. Zero values fall back to sane defaults.
+	HealthCheck ProbeSpec
+
+	// Resources are the compute resource requests/limits applied to the rgw
+	// container, and the signal the HorizontalPodAutoscaler scales against.
+	Resources v1.ResourceRequirements
+
+	// MinReplicas, MaxReplicas and TargetCPUUtilization, when all set,
+	// create a HorizontalPodAutoscaler and a PodDisruptionBudget for the rgw
+	// deployment instead of a fixed Replicas count.
+	MinReplicas          *int32
+	MaxReplicas          *int32
+	TargetCPUUtilization *int32
+
+	stopSync      chan struct{}
+	stopCertWatch chan struct{}
+}
+
+// ProbeSpec configures an HTTP readiness or liveness probe.
+type ProbeSpec struct {
+	InitialDelaySeconds int32
+	TimeoutSeconds      int32
+	PeriodSeconds       int32
+}
+
+// GatewaySpec configures how the rgw service is exposed outside the
+// cluster's internal ClusterIP, including optional TLS termination.
+type GatewaySpec struct {
+	// ServiceType is the type of the rgw service, e.g. ClusterIP, NodePort
+	// or LoadBalancer. Defaults to ClusterIP when empty.
+	ServiceType v1.ServiceType
+
+	// ExternalPort is the port exposed on the service for the plain (or, if
+	// AllPortsSecure is set together with SSLCertificateRef, TLS) listener.
+	// Defaults to the RGW port, or to 443 in that all-secure TLS mode.
+	ExternalPort int32
+
+	// SSLCertificateRef names a Secret in the cluster's namespace used by the
+	// rgw pod itself to terminate TLS on a second port. civetweb's
+	// ssl_certificate option takes a single file containing the certificate
+	// and key concatenated together, so the secret must store that combined
+	// PEM under the key named by sslCertFileName ("rgw-cert.pem") - a
+	// standard kubernetes.io/tls secret (tls.crt/tls.key) will not work here.
+	SSLCertificateRef string
+
+	// AllPortsSecure serves only the secure port rather than exposing both
+	// the plain and TLS listeners.
+	AllPortsSecure bool
+
+	// ExposeViaIngress additionally creates an Ingress for the rgw service.
+	ExposeViaIngress bool
+
+	// Host is the ingress host. Required when ExposeViaIngress is set.
+	Host string
+
+	// IngressSSLCertificateRef names a standard kubernetes.io/tls Secret
+	// (tls.crt/tls.key) used by the Ingress controller to terminate TLS for
+	// the ingress. This is deliberately a separate field from
+	// SSLCertificateRef: civetweb and the ingress controller expect
+	// differently-shaped secrets, so the same secret can't serve both.
+	IngressSSLCertificateRef string
 }
 
 // New creates an instance of an rgw manager
@@ -63,7 +174,23 @@ func New(context *clusterd.Context, name, namespace, version string, placement k
 	}
 }
 
-// Start the rgw manager
+// isSecondary returns true when this cluster is configured to join an
+// existing realm as a pull-zone secondary rather than creating its own.
+func (c *Cluster) isSecondary() bool {
+	return c.MasterRealmEndpoint != ""
+}
+
+func (c *Cluster) zoneName() string {
+	if c.Zone != "" {
+		return c.Zone
+	}
+	return c.Name
+}
+
+// Start the rgw manager. Start is safe to call repeatedly, e.g. once per
+// operator reconcile: it never leaves more than one copy of each background
+// goroutine running, and callers that are done with a Cluster for good
+// should call Stop to shut those goroutines down.
 func (c *Cluster) Start() error {
 	logger.Infof("start running rgw")
 
@@ -79,6 +206,9 @@ func (c *Cluster) Start() error {
 	}
 
 	err = c.createRealm(serviceIP)
+	if err != nil {
+		return fmt.Errorf("failed to create rgw realm. %+v", err)
+	}
 
 	// start the deployment
 	deployment := c.makeDeployment()
@@ -92,53 +222,293 @@ func (c *Cluster) Start() error {
 		logger.Infof("rgw deployment started")
 	}
 
+	c.startBackgroundReconcilers()
+
+	if c.Gateway.ExposeViaIngress {
+		if err := c.createIngress(); err != nil {
+			return fmt.Errorf("failed to create rgw ingress. %+v", err)
+		}
+	}
+
+	if c.autoscaleEnabled() {
+		if err := c.createHorizontalPodAutoscaler(); err != nil {
+			return fmt.Errorf("failed to create rgw autoscaler. %+v", err)
+		}
+		if err := c.createPodDisruptionBudget(); err != nil {
+			return fmt.Errorf("failed to create rgw pod disruption budget. %+v", err)
+		}
+	}
+
 	return nil
 }
 
+// autoscaleEnabled returns true when the user has configured the cluster to
+// be scaled by a HorizontalPodAutoscaler rather than a fixed Replicas count.
+func (c *Cluster) autoscaleEnabled() bool {
+	return c.MinReplicas != nil && c.MaxReplicas != nil && c.TargetCPUUtilization != nil
+}
+
+// Stop halts any background reconciliation started by Start, such as the
+// secondary zone period sync goroutine and the certificate rotation
+// watcher. The operator must call Stop when a Cluster is torn down (e.g. its
+// CephObjectStore CR is deleted) so these goroutines don't leak.
+func (c *Cluster) Stop() {
+	if c.stopSync != nil {
+		close(c.stopSync)
+		c.stopSync = nil
+	}
+	if c.stopCertWatch != nil {
+		close(c.stopCertWatch)
+		c.stopCertWatch = nil
+	}
+}
+
+// startBackgroundReconcilers (re)starts the goroutines Start depends on,
+// stopping any copies it previously started first so repeated calls to
+// Start never accumulate more than one of each.
+func (c *Cluster) startBackgroundReconcilers() {
+	c.Stop()
+
+	if c.isSecondary() {
+		c.stopSync = make(chan struct{})
+		go c.syncSecondaryZone(c.stopSync)
+	}
+
+	if c.Gateway.SSLCertificateRef != "" {
+		c.stopCertWatch = make(chan struct{})
+		go c.watchCertificateRotation(c.stopCertWatch)
+	}
+}
+
 type idType struct {
 	ID string `json:"id"`
 }
 
+// createRealm sets up the realm/zonegroup/zone this rgw instance serves,
+// either as a brand new master or as a secondary pulling from an existing
+// master zone, depending on whether MasterRealmEndpoint is configured.
 func (c *Cluster) createRealm(serviceIP string) error {
-	output, err := c.runRGWCommand("realm", "create", fmt.Sprintf("--rgw-realm=%s", c.Name))
-	if err != nil {
-		return fmt.Errorf("failed to create rgw realm %s. %+v", c.Name, err)
+	if c.isSecondary() {
+		return c.pullRealm(serviceIP)
 	}
+	return c.createMasterRealm(serviceIP)
+}
 
-	realmID, err := decodeID(output)
+// existingID looks up an existing realm/zonegroup/zone by running
+// "radosgw-admin <kind> get" with the given filter arg, returning its id if
+// one is already configured. It's used to make realm/zonegroup/zone
+// creation idempotent, since radosgw-admin's own create subcommands fail if
+// the object already exists.
+func (c *Cluster) existingID(kind, filterArg string) (string, bool) {
+	output, err := c.runRGWCommand(kind, "get", filterArg)
 	if err != nil {
-		return fmt.Errorf("failed to parse realm id. %+v", err)
+		return "", false
 	}
-
-	output, err = c.runRGWCommand("zonegroup", "create", "--master",
-		fmt.Sprintf("--endpoints=%s:%d", serviceIP, cephrgw.RGWPort),
-		fmt.Sprintf("--rgw-zonegroup=%s", c.Name),
-		fmt.Sprintf("--rgw-realm=%s", c.Name))
+	id, err := decodeID(output)
 	if err != nil {
-		return fmt.Errorf("failed to create rgw zonegroup for %s. %+v", c.Name, err)
+		return "", false
+	}
+	return id, true
+}
+
+// createMasterRealm is idempotent: Start calls it on every reconcile, so each
+// of the realm/zonegroup/zone it creates is first checked for existence
+// rather than assumed absent, since radosgw-admin's create subcommands fail
+// if their target already exists.
+func (c *Cluster) createMasterRealm(serviceIP string) error {
+	realmID, exists := c.existingID("realm", fmt.Sprintf("--rgw-realm=%s", c.Name))
+	if !exists {
+		output, err := c.runRGWCommand("realm", "create", fmt.Sprintf("--rgw-realm=%s", c.Name))
+		if err != nil {
+			return fmt.Errorf("failed to create rgw realm %s. %+v", c.Name, err)
+		}
+		realmID, err = decodeID(output)
+		if err != nil {
+			return fmt.Errorf("failed to parse realm id. %+v", err)
+		}
+	}
+
+	zoneGroupID, exists := c.existingID("zonegroup", fmt.Sprintf("--rgw-zonegroup=%s", c.Name))
+	if !exists {
+		output, err := c.runRGWCommand("zonegroup", "create", "--master",
+			fmt.Sprintf("--endpoints=%s:%d", serviceIP, cephrgw.RGWPort),
+			fmt.Sprintf("--rgw-zonegroup=%s", c.Name),
+			fmt.Sprintf("--rgw-realm=%s", c.Name))
+		if err != nil {
+			return fmt.Errorf("failed to create rgw zonegroup for %s. %+v", c.Name, err)
+		}
+		zoneGroupID, err = decodeID(output)
+		if err != nil {
+			return fmt.Errorf("failed to parse zonegroup id. %+v", err)
+		}
 	}
 
-	zoneGroupID, err := decodeID(output)
+	zoneID, exists := c.existingID("zone", fmt.Sprintf("--rgw-zone=%s", c.zoneName()))
+	if !exists {
+		output, err := c.runRGWCommand("zone", "create", "--master",
+			fmt.Sprintf("--endpoints=%s:%d", serviceIP, cephrgw.RGWPort),
+			fmt.Sprintf("--rgw-zone=%s", c.zoneName()),
+			fmt.Sprintf("--rgw-zonegroup=%s", c.Name),
+			fmt.Sprintf("--rgw-realm=%s", c.Name))
+		if err != nil {
+			return fmt.Errorf("failed to create rgw zone for %s. %+v", c.Name, err)
+		}
+		zoneID, err = decodeID(output)
+		if err != nil {
+			return fmt.Errorf("failed to parse zone id. %+v", err)
+		}
+	}
+
+	logger.Infof("RGW: realm=%s, zonegroup=%s, zone=%s", realmID, zoneGroupID, zoneID)
+	return nil
+}
+
+// pullRealm joins this cluster to an existing realm hosted by a master zone
+// in a remote cluster. It pulls the realm and period from the master, then
+// creates a secondary zone in the existing zonegroup that replicates from
+// it. If a master zone with this name already exists locally, the pull is
+// refused unless AllowMasterZoneDowngrade is set, since demoting a master
+// zone can break clusters already replicating from it.
+func (c *Cluster) pullRealm(serviceIP string) error {
+	isMaster, err := c.currentZoneIsMaster()
 	if err != nil {
-		return fmt.Errorf("failed to parse realm id. %+v", err)
+		return fmt.Errorf("failed to determine current zone state. %+v", err)
+	}
+	if isMaster && !c.AllowMasterZoneDowngrade {
+		return fmt.Errorf("zone %s is currently a master zone; refusing to convert it to a secondary "+
+			"without AllowMasterZoneDowngrade", c.zoneName())
 	}
 
-	output, err = c.runRGWCommand("zone", "create", "--master",
-		fmt.Sprintf("--endpoints=%s:%d", serviceIP, cephrgw.RGWPort),
-		fmt.Sprintf("--rgw-zone=%s", c.Name),
-		fmt.Sprintf("--rgw-zonegroup=%s", c.Name),
-		fmt.Sprintf("--rgw-realm=%s", c.Name))
+	// Start must not block the operator's reconcile loop for minutes on an
+	// unreachable master, so this is a single attempt: if the master isn't
+	// up yet, Start returns an error and is retried on the next reconcile.
+	// The background syncSecondaryZone goroutine is where the long
+	// exponential-backoff retry against a flaky master belongs.
+	if _, err := c.runRGWCommand("realm", "pull",
+		fmt.Sprintf("--url=%s", c.MasterRealmEndpoint),
+		fmt.Sprintf("--access-key=%s", c.AccessKey),
+		fmt.Sprintf("--secret=%s", c.SecretKey)); err != nil {
+		return fmt.Errorf("failed to pull realm from %s. %+v", c.MasterRealmEndpoint, err)
+	}
+
+	if _, err := c.runRGWCommand("period", "pull",
+		fmt.Sprintf("--url=%s", c.MasterRealmEndpoint),
+		fmt.Sprintf("--access-key=%s", c.AccessKey),
+		fmt.Sprintf("--secret=%s", c.SecretKey)); err != nil {
+		return fmt.Errorf("failed to pull period from %s. %+v", c.MasterRealmEndpoint, err)
+	}
+
+	// zone create fails if the secondary zone already exists, which it will
+	// on every reconcile after the first, so check for it first to keep
+	// pullRealm safe to call repeatedly
+	zoneID, exists := c.existingID("zone", fmt.Sprintf("--rgw-zone=%s", c.zoneName()))
+	if !exists {
+		output, err := c.runRGWCommand("zone", "create",
+			fmt.Sprintf("--endpoints=%s:%d", serviceIP, cephrgw.RGWPort),
+			fmt.Sprintf("--rgw-zone=%s", c.zoneName()),
+			fmt.Sprintf("--rgw-zonegroup=%s", c.Name),
+			fmt.Sprintf("--access-key=%s", c.AccessKey),
+			fmt.Sprintf("--secret=%s", c.SecretKey))
+		if err != nil {
+			return fmt.Errorf("failed to create secondary rgw zone %s. %+v", c.zoneName(), err)
+		}
+		zoneID, err = decodeID(output)
+		if err != nil {
+			return fmt.Errorf("failed to parse zone id. %+v", err)
+		}
+	}
+
+	if _, err := c.runRGWCommand("period", "update", "--commit"); err != nil {
+		return fmt.Errorf("failed to commit period after joining as secondary zone. %+v", err)
+	}
+
+	logger.Infof("RGW: joined realm %s as secondary zone=%s (%s)", c.Name, c.zoneName(), zoneID)
+	return nil
+}
+
+// pullRealmWithRetry pulls the realm from the master endpoint, retrying with
+// an exponential backoff since the master endpoint can go temporarily
+// unreachable. Used only by the background syncSecondaryZone goroutine -
+// the blocking Start path makes a single attempt instead so a slow or
+// down master doesn't stall the operator's reconcile loop.
+func (c *Cluster) pullRealmWithRetry() error {
+	backoff := pullRetryInitialBackoff
+	var lastErr error
+	for attempt := 0; attempt < 10; attempt++ {
+		_, err := c.runRGWCommand("realm", "pull",
+			fmt.Sprintf("--url=%s", c.MasterRealmEndpoint),
+			fmt.Sprintf("--access-key=%s", c.AccessKey),
+			fmt.Sprintf("--secret=%s", c.SecretKey))
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		logger.Warningf("failed to pull realm from %s (attempt %d), retrying in %s. %+v",
+			c.MasterRealmEndpoint, attempt+1, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > pullRetryMaxBackoff {
+			backoff = pullRetryMaxBackoff
+		}
+	}
+	return lastErr
+}
+
+// currentZoneIsMaster returns whether a zone with this cluster's zone name
+// is already configured locally as the realm's master zone.
+func (c *Cluster) currentZoneIsMaster() (bool, error) {
+	zoneOutput, err := c.runRGWCommand("zone", "get", fmt.Sprintf("--rgw-zone=%s", c.zoneName()))
 	if err != nil {
-		return fmt.Errorf("failed to create rgw zonegroup for %s. %+v", c.Name, err)
+		// the zone doesn't exist yet, so there is nothing to downgrade
+		return false, nil
 	}
 
-	zoneID, err := decodeID(output)
+	var zone struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(zoneOutput), &zone); err != nil {
+		return false, fmt.Errorf("failed to unmarshal zone info: %+v", err)
+	}
+
+	// master designation isn't recorded on the zone itself; it's the
+	// zonegroup that names which of its zones is the master_zone
+	zonegroupOutput, err := c.runRGWCommand("zonegroup", "get", fmt.Sprintf("--rgw-zonegroup=%s", c.Name))
 	if err != nil {
-		return fmt.Errorf("failed to parse zone id. %+v", err)
+		return false, fmt.Errorf("failed to get zonegroup %s. %+v", c.Name, err)
 	}
 
-	logger.Infof("RGW: realm=%s, zonegroup=%s, zone=%s", realmID, zoneGroupID, zoneID)
-	return nil
+	var zonegroup struct {
+		MasterZone string `json:"master_zone"`
+	}
+	if err := json.Unmarshal([]byte(zonegroupOutput), &zonegroup); err != nil {
+		return false, fmt.Errorf("failed to unmarshal zonegroup info: %+v", err)
+	}
+
+	return zonegroup.MasterZone != "" && zonegroup.MasterZone == zone.ID, nil
+}
+
+// syncSecondaryZone periodically commits a period update and re-pulls the
+// realm configuration so that zone/zonegroup changes made on the master
+// (new zones, endpoint changes, etc) propagate to this secondary.
+func (c *Cluster) syncSecondaryZone(stopCh chan struct{}) {
+	ticker := time.NewTicker(periodUpdateInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			logger.Infof("stopping rgw secondary zone sync for %s", c.Name)
+			return
+		case <-ticker.C:
+			if err := c.pullRealmWithRetry(); err != nil {
+				logger.Errorf("failed to re-pull realm during secondary sync. %+v", err)
+				continue
+			}
+			if _, err := c.runRGWCommand("period", "update", "--commit"); err != nil {
+				logger.Errorf("failed to commit period update during secondary sync. %+v", err)
+			}
+		}
+	}
 }
 
 func decodeID(data string) (string, error) {
@@ -151,6 +521,14 @@ func decodeID(data string) (string, error) {
 	return id.ID, err
 }
 
+// RunAdminCommand runs a radosgw-admin command with this cluster's admin
+// connection args; it adds no realm, zonegroup or zone args of its own.
+// It is exported so other rgw subsystems, such as the bucket provisioner,
+// can reuse the same admin connection instead of shelling out on their own.
+func (c *Cluster) RunAdminCommand(args ...string) (string, error) {
+	return c.runRGWCommand(args...)
+}
+
 func (c *Cluster) runRGWCommand(args ...string) (string, error) {
 	options := client.AppendAdminConnectionArgs(args, c.context.ConfigDir, c.Namespace)
 
@@ -183,6 +561,13 @@ func (c *Cluster) createKeyring() error {
 	secrets := map[string]string{
 		keyringName: keyring,
 	}
+	if c.isSecondary() {
+		// persist the replication credentials alongside the keyring; the
+		// secondary sync goroutine itself pulls with c.AccessKey/c.SecretKey
+		// and does not read them back from this secret
+		secrets[accessKeyName] = c.AccessKey
+		secrets[secretKeyName] = c.SecretKey
+	}
 	secret := &v1.Secret{
 		ObjectMeta: metav1.ObjectMeta{Name: c.instanceName(), Namespace: c.Namespace},
 		StringData: secrets,
@@ -209,13 +594,30 @@ func (c *Cluster) makeDeployment() *extensions.Deployment {
 	deployment.Name = c.instanceName()
 	deployment.Namespace = c.Namespace
 
+	volumes := []v1.Volume{
+		{Name: k8sutil.DataDirVolume, VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+		k8sutil.ConfigOverrideVolume(),
+	}
+	if c.Gateway.SSLCertificateRef != "" {
+		volumes = append(volumes, v1.Volume{
+			Name: sslCertVolumeName,
+			VolumeSource: v1.VolumeSource{
+				Secret: &v1.SecretVolumeSource{
+					SecretName: c.Gateway.SSLCertificateRef,
+					// civetweb's ssl_certificate path is fixed, so the
+					// secret's combined-PEM key is mapped explicitly to
+					// that file name regardless of what key the secret
+					// happens to use
+					Items: []v1.KeyToPath{{Key: sslCertFileName, Path: sslCertFileName}},
+				},
+			},
+		})
+	}
+
 	podSpec := v1.PodSpec{
 		Containers:    []v1.Container{c.rgwContainer()},
 		RestartPolicy: v1.RestartPolicyAlways,
-		Volumes: []v1.Volume{
-			{Name: k8sutil.DataDirVolume, VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
-			k8sutil.ConfigOverrideVolume(),
-		},
+		Volumes:       volumes,
 	}
 	c.placement.ApplyToPodSpec(&podSpec)
 
@@ -228,27 +630,56 @@ func (c *Cluster) makeDeployment() *extensions.Deployment {
 		Spec: podSpec,
 	}
 
-	deployment.Spec = extensions.DeploymentSpec{Template: podTemplateSpec, Replicas: &c.Replicas}
+	replicas := &c.Replicas
+	if c.autoscaleEnabled() {
+		// the HPA takes over scaling from here; start at its floor
+		replicas = c.MinReplicas
+	}
+	deployment.Spec = extensions.DeploymentSpec{Template: podTemplateSpec, Replicas: replicas}
 
 	return deployment
 }
 
 func (c *Cluster) rgwContainer() v1.Container {
 
+	args := []string{
+		"rgw",
+		fmt.Sprintf("--config-dir=%s", k8sutil.DataDir),
+		fmt.Sprintf("--rgw-name=%s", c.Name),
+		fmt.Sprintf("--rgw-port=%d", cephrgw.RGWPort),
+		fmt.Sprintf("--rgw-host=%s", cephrgw.DNSName),
+		fmt.Sprintf("--rgw-realm=%s", c.Name),
+		fmt.Sprintf("--rgw-zonegroup=%s", c.Name),
+		fmt.Sprintf("--rgw-zone=%s", c.zoneName()),
+	}
+
+	volumeMounts := []v1.VolumeMount{
+		{Name: k8sutil.DataDirVolume, MountPath: k8sutil.DataDir},
+		k8sutil.ConfigOverrideMount(),
+	}
+
+	if c.Gateway.SSLCertificateRef != "" {
+		port := fmt.Sprintf("%d", cephrgw.RGWPort)
+		if c.Gateway.AllPortsSecure {
+			port = ""
+		} else {
+			port += "+"
+		}
+		frontends := fmt.Sprintf("civetweb port=%s%ds ssl_certificate=%s/%s",
+			port, defaultSecurePort, sslCertMountPath, sslCertFileName)
+		args = append(args, fmt.Sprintf("--rgw-frontends=%s", frontends))
+
+		volumeMounts = append(volumeMounts, v1.VolumeMount{Name: sslCertVolumeName, MountPath: sslCertMountPath, ReadOnly: true})
+	}
+
 	return v1.Container{
-		Args: []string{
-			"rgw",
-			fmt.Sprintf("--config-dir=%s", k8sutil.DataDir),
-			fmt.Sprintf("--rgw-name=%s", c.Name),
-			fmt.Sprintf("--rgw-port=%d", cephrgw.RGWPort),
-			fmt.Sprintf("--rgw-host=%s", cephrgw.DNSName),
-		},
-		Name:  c.instanceName(),
-		Image: k8sutil.MakeRookImage(c.Version),
-		VolumeMounts: []v1.VolumeMount{
-			{Name: k8sutil.DataDirVolume, MountPath: k8sutil.DataDir},
-			k8sutil.ConfigOverrideMount(),
-		},
+		Args:           args,
+		Name:           c.instanceName(),
+		Image:          k8sutil.MakeRookImage(c.Version),
+		VolumeMounts:   volumeMounts,
+		Resources:      c.Resources,
+		ReadinessProbe: c.rgwProbe(),
+		LivenessProbe:  c.rgwProbe(),
 		Env: []v1.EnvVar{
 			{Name: "ROOK_RGW_KEYRING", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: c.instanceName()}, Key: keyringName}}},
 			k8sutil.PodIPEnvVar(k8sutil.PrivateIPEnvVar),
@@ -262,8 +693,81 @@ func (c *Cluster) rgwContainer() v1.Container {
 	}
 }
 
+// rgwProbe builds the probe used for both readiness and liveness. Anonymous
+// S3 ListBuckets calls against civetweb's "/" answer with a 403, which a
+// Kubernetes httpGet probe (2xx-399 only) treats as failure, so a plain TCP
+// check against the listening port is used instead. The port it checks
+// follows Gateway.AllPortsSecure, but only once TLS is actually configured
+// (an SSLCertificateRef set) - without a cert civetweb never binds the
+// secure port at all, AllPortsSecure or not.
+func (c *Cluster) rgwProbe() *v1.Probe {
+	initialDelay := c.HealthCheck.InitialDelaySeconds
+	if initialDelay == 0 {
+		initialDelay = defaultProbeInitialDelaySeconds
+	}
+	timeout := c.HealthCheck.TimeoutSeconds
+	if timeout == 0 {
+		timeout = defaultProbeTimeoutSeconds
+	}
+	period := c.HealthCheck.PeriodSeconds
+	if period == 0 {
+		period = defaultProbePeriodSeconds
+	}
+
+	port := int32(cephrgw.RGWPort)
+	if c.Gateway.SSLCertificateRef != "" && c.Gateway.AllPortsSecure {
+		port = defaultSecurePort
+	}
+
+	return &v1.Probe{
+		Handler: v1.Handler{
+			TCPSocket: &v1.TCPSocketAction{
+				Port: intstr.FromInt(int(port)),
+			},
+		},
+		InitialDelaySeconds: initialDelay,
+		TimeoutSeconds:      timeout,
+		PeriodSeconds:       period,
+	}
+}
+
 func (c *Cluster) startService() (string, error) {
 	labels := c.getLabels()
+
+	secure := c.Gateway.SSLCertificateRef != ""
+
+	externalPort := c.Gateway.ExternalPort
+	if externalPort == 0 {
+		if secure && c.Gateway.AllPortsSecure {
+			externalPort = defaultSecurePort
+		} else {
+			externalPort = cephrgw.RGWPort
+		}
+	}
+
+	var ports []v1.ServicePort
+	if !secure || !c.Gateway.AllPortsSecure {
+		ports = append(ports, v1.ServicePort{
+			Name:       "http",
+			Port:       externalPort,
+			TargetPort: intstr.FromInt(int(cephrgw.RGWPort)),
+			Protocol:   v1.ProtocolTCP,
+		})
+	}
+	if secure {
+		securePort := int32(defaultSecurePort)
+		if c.Gateway.AllPortsSecure {
+			// ExternalPort describes the single exposed listener in this mode
+			securePort = externalPort
+		}
+		ports = append(ports, v1.ServicePort{
+			Name:       "https",
+			Port:       securePort,
+			TargetPort: intstr.FromInt(defaultSecurePort),
+			Protocol:   v1.ProtocolTCP,
+		})
+	}
+
 	s := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      c.instanceName(),
@@ -271,15 +775,9 @@ func (c *Cluster) startService() (string, error) {
 			Labels:    labels,
 		},
 		Spec: v1.ServiceSpec{
-			Ports: []v1.ServicePort{
-				{
-					Name:       c.instanceName(),
-					Port:       cephrgw.RGWPort,
-					TargetPort: intstr.FromInt(int(cephrgw.RGWPort)),
-					Protocol:   v1.ProtocolTCP,
-				},
-			},
+			Ports:    ports,
 			Selector: labels,
+			Type:     c.Gateway.ServiceType,
 		},
 	}
 
@@ -303,3 +801,174 @@ func (c *Cluster) getLabels() map[string]string {
 		"rook_object_store": c.Name,
 	}
 }
+
+// createIngress exposes the rgw service through an Ingress, terminating TLS
+// there when an IngressSSLCertificateRef is configured. This is a distinct
+// secret from Gateway.SSLCertificateRef: the ingress controller expects a
+// standard kubernetes.io/tls secret, not civetweb's combined-PEM format.
+func (c *Cluster) createIngress() error {
+	servicePort := intstr.FromInt(int(cephrgw.RGWPort))
+	if c.Gateway.SSLCertificateRef != "" && c.Gateway.AllPortsSecure {
+		servicePort = intstr.FromInt(defaultSecurePort)
+	}
+	if c.Gateway.ExternalPort != 0 {
+		// startService exposes the listener on ExternalPort when it is set
+		servicePort = intstr.FromInt(int(c.Gateway.ExternalPort))
+	}
+
+	ingress := &extensions.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.instanceName(),
+			Namespace: c.Namespace,
+			Labels:    c.getLabels(),
+		},
+		Spec: extensions.IngressSpec{
+			Rules: []extensions.IngressRule{
+				{
+					Host: c.Gateway.Host,
+					IngressRuleValue: extensions.IngressRuleValue{
+						HTTP: &extensions.HTTPIngressRuleValue{
+							Paths: []extensions.HTTPIngressPath{
+								{
+									Backend: extensions.IngressBackend{
+										ServiceName: c.instanceName(),
+										ServicePort: servicePort,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if c.Gateway.IngressSSLCertificateRef != "" {
+		ingress.Spec.TLS = []extensions.IngressTLS{
+			{Hosts: []string{c.Gateway.Host}, SecretName: c.Gateway.IngressSSLCertificateRef},
+		}
+	}
+
+	_, err := c.context.Clientset.ExtensionsV1beta1().Ingresses(c.Namespace).Create(ingress)
+	if err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create rgw ingress. %+v", err)
+		}
+		logger.Infof("rgw ingress already exists")
+	}
+	return nil
+}
+
+// watchCertificateRotation polls the SSL certificate secret's resourceVersion
+// and restarts the rgw deployment when it changes, so a rotated certificate
+// actually gets picked up by the running pods.
+func (c *Cluster) watchCertificateRotation(stopCh chan struct{}) {
+	lastResourceVersion := ""
+	ticker := time.NewTicker(certWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			secret, err := c.context.Clientset.CoreV1().Secrets(c.Namespace).Get(c.Gateway.SSLCertificateRef, metav1.GetOptions{})
+			if err != nil {
+				logger.Errorf("failed to check rgw ssl certificate secret %s for rotation. %+v", c.Gateway.SSLCertificateRef, err)
+				continue
+			}
+			if lastResourceVersion == "" {
+				lastResourceVersion = secret.ResourceVersion
+				continue
+			}
+			if secret.ResourceVersion == lastResourceVersion {
+				continue
+			}
+
+			logger.Infof("rgw ssl certificate %s changed, restarting rgw deployment", c.Gateway.SSLCertificateRef)
+			if err := c.restartDeployment(); err != nil {
+				logger.Errorf("failed to restart rgw deployment after certificate rotation. %+v", err)
+				continue
+			}
+			lastResourceVersion = secret.ResourceVersion
+		}
+	}
+}
+
+// restartDeployment forces a rolling restart by updating an annotation on
+// the pod template, since the secret volume isn't automatically remounted.
+func (c *Cluster) restartDeployment() error {
+	deployments := c.context.Clientset.ExtensionsV1beta1().Deployments(c.Namespace)
+	deployment, err := deployments.Get(c.instanceName(), metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations["rook.io/rgw-cert-restarted-at"] = deployment.ResourceVersion
+
+	_, err = deployments.Update(deployment)
+	return err
+}
+
+// createHorizontalPodAutoscaler scales the rgw deployment between
+// MinReplicas and MaxReplicas to hit TargetCPUUtilization.
+func (c *Cluster) createHorizontalPodAutoscaler() error {
+	hpa := &autoscalingv1.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.instanceName(),
+			Namespace: c.Namespace,
+			Labels:    c.getLabels(),
+		},
+		Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{
+				Kind:       "Deployment",
+				Name:       c.instanceName(),
+				APIVersion: "extensions/v1beta1",
+			},
+			MinReplicas:                    c.MinReplicas,
+			MaxReplicas:                    *c.MaxReplicas,
+			TargetCPUUtilizationPercentage: c.TargetCPUUtilization,
+		},
+	}
+
+	_, err := c.context.Clientset.AutoscalingV1().HorizontalPodAutoscalers(c.Namespace).Create(hpa)
+	if err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return err
+		}
+		logger.Infof("rgw autoscaler already exists")
+	}
+	return nil
+}
+
+// createPodDisruptionBudget limits voluntary disruptions, such as node
+// drains, to one rgw replica at a time. MinAvailable would go to zero (and
+// so stop protecting anything) whenever MinReplicas is 1, so MaxUnavailable
+// is used instead: it caps disruption at one pod regardless of replica count.
+func (c *Cluster) createPodDisruptionBudget() error {
+	maxUnavailable := intstr.FromInt(1)
+
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.instanceName(),
+			Namespace: c.Namespace,
+			Labels:    c.getLabels(),
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector:       &metav1.LabelSelector{MatchLabels: c.getLabels()},
+		},
+	}
+
+	_, err := c.context.Clientset.PolicyV1beta1().PodDisruptionBudgets(c.Namespace).Create(pdb)
+	if err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return err
+		}
+		logger.Infof("rgw pod disruption budget already exists")
+	}
+	return nil
+}